@@ -0,0 +1,19 @@
+package gocsi
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var addrRX = regexp.MustCompile(
+	`(?i)^((?:(?:tcp|udp|ip)[46]?)|(?:unix(?:gram|packet)?))://(.+)$`)
+
+// ParseProtoAddr parses a Golang network address of the form
+// "proto://addr", e.g. "tcp://127.0.0.1:9090" or "unix:///tmp/csi.sock".
+func ParseProtoAddr(protoAddr string) (proto string, addr string, err error) {
+	m := addrRX.FindStringSubmatch(protoAddr)
+	if m == nil {
+		return "", "", fmt.Errorf("invalid address: %v", protoAddr)
+	}
+	return m[1], m[2], nil
+}
@@ -0,0 +1,105 @@
+package gocsi
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/container-storage-interface/libraries/gocsi/csi"
+)
+
+// Client wraps a Service and remembers the VolumeContext (the Attributes
+// on the VolumeInfo a CreateVolume call returns), re-attaching it as
+// VolumeAttributes on subsequent Controller/Node publish calls for that
+// volume. This mirrors the Nomad CSI plug-in's parameters/context/secrets
+// plumbing, sparing callers from having to thread a volume's attributes
+// through every RPC themselves.
+type Client struct {
+	svc Service
+
+	mu       sync.Mutex
+	contexts map[string]map[string]string // volume id -> VolumeInfo.Attributes
+}
+
+// NewClient returns a Client that proxies CreateVolume, DeleteVolume,
+// ControllerPublishVolume, and NodePublishVolume to svc. Client does not
+// expose the other CSI RPCs; call those on svc directly.
+func NewClient(svc Service) *Client {
+	return &Client{svc: svc, contexts: map[string]map[string]string{}}
+}
+
+// CreateVolume creates the volume via the wrapped Service and, if the
+// response carries VolumeInfo.Attributes, remembers them so later
+// ControllerPublishVolume and NodePublishVolume calls for the same volume
+// don't need to repeat them.
+func (c *Client) CreateVolume(
+	ctx context.Context,
+	req *csi.CreateVolumeRequest) (
+	*csi.CreateVolumeResponse, error) {
+
+	resp, err := c.svc.CreateVolume(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if vi := resp.GetVolumeInfo(); vi != nil {
+		if attrs := vi.GetAttributes(); len(attrs) > 0 {
+			id := vi.GetId().GetValues()["id"]
+			c.mu.Lock()
+			c.contexts[id] = attrs
+			c.mu.Unlock()
+		}
+	}
+
+	return resp, nil
+}
+
+// DeleteVolume deletes the volume via the wrapped Service and forgets its
+// remembered VolumeContext, if any.
+func (c *Client) DeleteVolume(
+	ctx context.Context,
+	req *csi.DeleteVolumeRequest) (
+	*csi.DeleteVolumeResponse, error) {
+
+	resp, err := c.svc.DeleteVolume(ctx, req)
+	if err == nil {
+		c.mu.Lock()
+		delete(c.contexts, req.GetVolumeId().GetValues()["id"])
+		c.mu.Unlock()
+	}
+	return resp, err
+}
+
+// ControllerPublishVolume publishes the volume via the wrapped Service,
+// filling in req.VolumeAttributes from the volume's remembered
+// VolumeContext if the caller didn't already set it.
+func (c *Client) ControllerPublishVolume(
+	ctx context.Context,
+	req *csi.ControllerPublishVolumeRequest) (
+	*csi.ControllerPublishVolumeResponse, error) {
+
+	if len(req.GetVolumeAttributes()) == 0 {
+		req.VolumeAttributes = c.volumeContext(req.GetVolumeId().GetValues()["id"])
+	}
+	return c.svc.ControllerPublishVolume(ctx, req)
+}
+
+// NodePublishVolume publishes the volume via the wrapped Service, filling
+// in req.VolumeAttributes from the volume's remembered VolumeContext if
+// the caller didn't already set it.
+func (c *Client) NodePublishVolume(
+	ctx context.Context,
+	req *csi.NodePublishVolumeRequest) (
+	*csi.NodePublishVolumeResponse, error) {
+
+	if len(req.GetVolumeAttributes()) == 0 {
+		req.VolumeAttributes = c.volumeContext(req.GetVolumeId().GetValues()["id"])
+	}
+	return c.svc.NodePublishVolume(ctx, req)
+}
+
+func (c *Client) volumeContext(volID string) map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.contexts[volID]
+}
@@ -0,0 +1,212 @@
+package fake
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	"github.com/container-storage-interface/libraries/gocsi/csi"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+//                              Identity Client                               //
+////////////////////////////////////////////////////////////////////////////////
+
+// identityClient adapts a Service to csi.IdentityClient, dispatching
+// directly to the in-memory Service instead of over a gRPC connection.
+type identityClient struct {
+	svc *Service
+}
+
+// NewIdentityClient returns a csi.IdentityClient backed by svc, for
+// callers that want to drive the fake's identity RPCs the same way they
+// would a real plug-in's generated client.
+func NewIdentityClient(svc *Service) csi.IdentityClient {
+	return &identityClient{svc: svc}
+}
+
+func (c *identityClient) GetSupportedVersions(
+	ctx context.Context,
+	in *csi.GetSupportedVersionsRequest,
+	opts ...grpc.CallOption) (
+	*csi.GetSupportedVersionsResponse, error) {
+
+	return c.svc.GetSupportedVersions(ctx, in)
+}
+
+func (c *identityClient) GetPluginInfo(
+	ctx context.Context,
+	in *csi.GetPluginInfoRequest,
+	opts ...grpc.CallOption) (
+	*csi.GetPluginInfoResponse, error) {
+
+	return c.svc.GetPluginInfo(ctx, in)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+//                             Controller Client                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// controllerClient adapts a Service to csi.ControllerClient, dispatching
+// directly to the in-memory Service instead of over a gRPC connection.
+type controllerClient struct {
+	svc *Service
+}
+
+// NewControllerClient returns a csi.ControllerClient backed by svc, for
+// callers that want to drive the fake's controller RPCs the same way
+// they would a real plug-in's generated client.
+func NewControllerClient(svc *Service) csi.ControllerClient {
+	return &controllerClient{svc: svc}
+}
+
+func (c *controllerClient) CreateVolume(
+	ctx context.Context,
+	in *csi.CreateVolumeRequest,
+	opts ...grpc.CallOption) (
+	*csi.CreateVolumeResponse, error) {
+
+	return c.svc.CreateVolume(ctx, in)
+}
+
+func (c *controllerClient) DeleteVolume(
+	ctx context.Context,
+	in *csi.DeleteVolumeRequest,
+	opts ...grpc.CallOption) (
+	*csi.DeleteVolumeResponse, error) {
+
+	return c.svc.DeleteVolume(ctx, in)
+}
+
+func (c *controllerClient) ControllerPublishVolume(
+	ctx context.Context,
+	in *csi.ControllerPublishVolumeRequest,
+	opts ...grpc.CallOption) (
+	*csi.ControllerPublishVolumeResponse, error) {
+
+	return c.svc.ControllerPublishVolume(ctx, in)
+}
+
+func (c *controllerClient) ControllerUnpublishVolume(
+	ctx context.Context,
+	in *csi.ControllerUnpublishVolumeRequest,
+	opts ...grpc.CallOption) (
+	*csi.ControllerUnpublishVolumeResponse, error) {
+
+	return c.svc.ControllerUnpublishVolume(ctx, in)
+}
+
+func (c *controllerClient) ValidateVolumeCapabilities(
+	ctx context.Context,
+	in *csi.ValidateVolumeCapabilitiesRequest,
+	opts ...grpc.CallOption) (
+	*csi.ValidateVolumeCapabilitiesResponse, error) {
+
+	return c.svc.ValidateVolumeCapabilities(ctx, in)
+}
+
+func (c *controllerClient) ListVolumes(
+	ctx context.Context,
+	in *csi.ListVolumesRequest,
+	opts ...grpc.CallOption) (
+	*csi.ListVolumesResponse, error) {
+
+	return c.svc.ListVolumes(ctx, in)
+}
+
+func (c *controllerClient) GetCapacity(
+	ctx context.Context,
+	in *csi.GetCapacityRequest,
+	opts ...grpc.CallOption) (
+	*csi.GetCapacityResponse, error) {
+
+	return c.svc.GetCapacity(ctx, in)
+}
+
+func (c *controllerClient) ControllerGetCapabilities(
+	ctx context.Context,
+	in *csi.ControllerGetCapabilitiesRequest,
+	opts ...grpc.CallOption) (
+	*csi.ControllerGetCapabilitiesResponse, error) {
+
+	return c.svc.ControllerGetCapabilities(ctx, in)
+}
+
+func (c *controllerClient) ControllerExpandVolume(
+	ctx context.Context,
+	in *csi.ControllerExpandVolumeRequest,
+	opts ...grpc.CallOption) (
+	*csi.ControllerExpandVolumeResponse, error) {
+
+	return c.svc.ControllerExpandVolume(ctx, in)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+//                                Node Client                                 //
+////////////////////////////////////////////////////////////////////////////////
+
+// nodeClient adapts a Service to csi.NodeClient, dispatching directly to
+// the in-memory Service instead of over a gRPC connection.
+type nodeClient struct {
+	svc *Service
+}
+
+// NewNodeClient returns a csi.NodeClient backed by svc, for callers that
+// want to drive the fake's node RPCs the same way they would a real
+// plug-in's generated client.
+func NewNodeClient(svc *Service) csi.NodeClient {
+	return &nodeClient{svc: svc}
+}
+
+func (c *nodeClient) NodePublishVolume(
+	ctx context.Context,
+	in *csi.NodePublishVolumeRequest,
+	opts ...grpc.CallOption) (
+	*csi.NodePublishVolumeResponse, error) {
+
+	return c.svc.NodePublishVolume(ctx, in)
+}
+
+func (c *nodeClient) NodeUnpublishVolume(
+	ctx context.Context,
+	in *csi.NodeUnpublishVolumeRequest,
+	opts ...grpc.CallOption) (
+	*csi.NodeUnpublishVolumeResponse, error) {
+
+	return c.svc.NodeUnpublishVolume(ctx, in)
+}
+
+func (c *nodeClient) GetNodeID(
+	ctx context.Context,
+	in *csi.GetNodeIDRequest,
+	opts ...grpc.CallOption) (
+	*csi.GetNodeIDResponse, error) {
+
+	return c.svc.GetNodeID(ctx, in)
+}
+
+func (c *nodeClient) ProbeNode(
+	ctx context.Context,
+	in *csi.ProbeNodeRequest,
+	opts ...grpc.CallOption) (
+	*csi.ProbeNodeResponse, error) {
+
+	return c.svc.ProbeNode(ctx, in)
+}
+
+func (c *nodeClient) NodeGetCapabilities(
+	ctx context.Context,
+	in *csi.NodeGetCapabilitiesRequest,
+	opts ...grpc.CallOption) (
+	*csi.NodeGetCapabilitiesResponse, error) {
+
+	return c.svc.NodeGetCapabilities(ctx, in)
+}
+
+func (c *nodeClient) NodeExpandVolume(
+	ctx context.Context,
+	in *csi.NodeExpandVolumeRequest,
+	opts ...grpc.CallOption) (
+	*csi.NodeExpandVolumeResponse, error) {
+
+	return c.svc.NodeExpandVolume(ctx, in)
+}
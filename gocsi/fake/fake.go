@@ -0,0 +1,559 @@
+// Package fake provides an in-memory implementation of gocsi.Service,
+// modelled on the Kubernetes fake CSI client, for use by downstream test
+// suites that want to drive specific CSI behavior without spinning up a
+// real plug-in.
+package fake
+
+import (
+	"net"
+	"strconv"
+	"sync"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/container-storage-interface/libraries/gocsi"
+	"github.com/container-storage-interface/libraries/gocsi/csi"
+)
+
+// Service is an in-memory gocsi.Service. Its zero value is not usable;
+// construct one with NewFakeService.
+type Service struct {
+	sync.Mutex
+
+	volumesByName map[string]*csi.VolumeInfo
+	volumesByID   map[string]*csi.VolumeInfo
+	order         []string // volume ids, oldest first, for ListVolumes paging
+
+	publications map[string]map[string]bool // volume id -> set of node ids
+	staged       map[string]string          // volume id -> staging target path
+	published    map[string]string          // volume id -> target path
+
+	nextErrs  map[string]error
+	nextResps map[string]interface{}
+}
+
+// NewFakeService returns an empty Service.
+func NewFakeService() *Service {
+	return &Service{
+		volumesByName: map[string]*csi.VolumeInfo{},
+		volumesByID:   map[string]*csi.VolumeInfo{},
+		publications:  map[string]map[string]bool{},
+		staged:        map[string]string{},
+		published:     map[string]string{},
+		nextErrs:      map[string]error{},
+		nextResps:     map[string]interface{}{},
+	}
+}
+
+// SetNextError arranges for the next call to the named RPC (e.g.
+// "CreateVolume") to return err instead of performing its normal logic.
+func (s *Service) SetNextError(method string, err error) {
+	s.Lock()
+	defer s.Unlock()
+	s.nextErrs[method] = err
+}
+
+// SetNextResponse arranges for the next call to the named RPC to return
+// resp instead of performing its normal logic. resp must be a pointer to
+// the RPC's response type, e.g. *csi.CreateVolumeResponse for
+// "CreateVolume".
+func (s *Service) SetNextResponse(method string, resp interface{}) {
+	s.Lock()
+	defer s.Unlock()
+	s.nextResps[method] = resp
+}
+
+// override returns a queued error or response for method, if any, and
+// clears it so it only applies once.
+func (s *Service) override(method string) (resp interface{}, err error, ok bool) {
+	s.Lock()
+	defer s.Unlock()
+	if err, ok := s.nextErrs[method]; ok {
+		delete(s.nextErrs, method)
+		return nil, err, true
+	}
+	if resp, ok := s.nextResps[method]; ok {
+		delete(s.nextResps, method)
+		return resp, nil, true
+	}
+	return nil, nil, false
+}
+
+////////////////////////////////////////////////////////////////////////////////
+//                                 Endpoint                                    //
+////////////////////////////////////////////////////////////////////////////////
+
+// Init is a no-op; the fake requires no setup.
+func (s *Service) Init(ctx context.Context) error {
+	return nil
+}
+
+// Serve registers the fake's Controller, Identity, and Node
+// implementations on a new gRPC server and serves it on li.
+func (s *Service) Serve(ctx context.Context, li net.Listener) error {
+	grpcServer := grpc.NewServer()
+	csi.RegisterControllerServer(grpcServer, s)
+	csi.RegisterIdentityServer(grpcServer, s)
+	csi.RegisterNodeServer(grpcServer, s)
+	return grpcServer.Serve(li)
+}
+
+// Shutdown is a no-op; callers that want a clean slate should construct a
+// new Service with NewFakeService instead.
+func (s *Service) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// ParameterSchema returns nil: the fake accepts any Parameters or
+// VolumeAttributes without validation, so table-driven tests don't have
+// to declare a schema just to exercise the fake.
+func (s *Service) ParameterSchema() gocsi.ParameterSchema {
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+//                            Controller Service                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// CreateVolume creates a volume named req.Name, or returns the existing
+// one of that name if it was already created.
+func (s *Service) CreateVolume(
+	ctx context.Context,
+	req *csi.CreateVolumeRequest) (
+	*csi.CreateVolumeResponse, error) {
+
+	if resp, err, ok := s.override("CreateVolume"); ok {
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*csi.CreateVolumeResponse), nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if v, ok := s.volumesByName[req.GetName()]; ok {
+		return &csi.CreateVolumeResponse{VolumeInfo: v}, nil
+	}
+
+	id := req.GetName()
+	v := &csi.VolumeInfo{
+		Id:            &csi.VolumeID{Values: map[string]string{"id": id}},
+		CapacityBytes: req.GetCapacityRange().GetRequiredBytes(),
+		Attributes:    req.GetParameters(),
+	}
+
+	s.volumesByName[req.GetName()] = v
+	s.volumesByID[id] = v
+	s.order = append(s.order, id)
+
+	return &csi.CreateVolumeResponse{VolumeInfo: v}, nil
+}
+
+// DeleteVolume deletes the volume identified by req.VolumeId. Deleting an
+// unknown volume is a no-op, matching the idempotent CSI contract.
+func (s *Service) DeleteVolume(
+	ctx context.Context,
+	req *csi.DeleteVolumeRequest) (
+	*csi.DeleteVolumeResponse, error) {
+
+	if resp, err, ok := s.override("DeleteVolume"); ok {
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*csi.DeleteVolumeResponse), nil
+	}
+
+	id := req.GetVolumeId().GetValues()["id"]
+
+	s.Lock()
+	defer s.Unlock()
+
+	if v, ok := s.volumesByID[id]; ok {
+		delete(s.volumesByID, id)
+		for name, vv := range s.volumesByName {
+			if vv == v {
+				delete(s.volumesByName, name)
+			}
+		}
+		for i, oid := range s.order {
+			if oid == id {
+				s.order = append(s.order[:i], s.order[i+1:]...)
+				break
+			}
+		}
+	}
+	delete(s.publications, id)
+
+	return &csi.DeleteVolumeResponse{}, nil
+}
+
+// ControllerPublishVolume records that req.NodeId is attached to
+// req.VolumeId.
+func (s *Service) ControllerPublishVolume(
+	ctx context.Context,
+	req *csi.ControllerPublishVolumeRequest) (
+	*csi.ControllerPublishVolumeResponse, error) {
+
+	if resp, err, ok := s.override("ControllerPublishVolume"); ok {
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*csi.ControllerPublishVolumeResponse), nil
+	}
+
+	volID := req.GetVolumeId().GetValues()["id"]
+	nodeID := req.GetNodeId().GetValues()["id"]
+
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.volumesByID[volID]; !ok {
+		return nil, status.Errorf(codes.NotFound, "volume not found: %s", volID)
+	}
+	if s.publications[volID] == nil {
+		s.publications[volID] = map[string]bool{}
+	}
+	s.publications[volID][nodeID] = true
+
+	return &csi.ControllerPublishVolumeResponse{}, nil
+}
+
+// ControllerUnpublishVolume removes req.NodeId from req.VolumeId's set of
+// attached nodes. An empty NodeId detaches every node, matching the CSI
+// spec's "unpublish from all nodes" behavior.
+func (s *Service) ControllerUnpublishVolume(
+	ctx context.Context,
+	req *csi.ControllerUnpublishVolumeRequest) (
+	*csi.ControllerUnpublishVolumeResponse, error) {
+
+	if resp, err, ok := s.override("ControllerUnpublishVolume"); ok {
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*csi.ControllerUnpublishVolumeResponse), nil
+	}
+
+	volID := req.GetVolumeId().GetValues()["id"]
+	nodeID := req.GetNodeId().GetValues()["id"]
+
+	s.Lock()
+	defer s.Unlock()
+
+	if nodeID == "" {
+		delete(s.publications, volID)
+	} else {
+		delete(s.publications[volID], nodeID)
+	}
+
+	return &csi.ControllerUnpublishVolumeResponse{}, nil
+}
+
+// ValidateVolumeCapabilities reports every requested capability as
+// supported for any known volume.
+func (s *Service) ValidateVolumeCapabilities(
+	ctx context.Context,
+	req *csi.ValidateVolumeCapabilitiesRequest) (
+	*csi.ValidateVolumeCapabilitiesResponse, error) {
+
+	if resp, err, ok := s.override("ValidateVolumeCapabilities"); ok {
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*csi.ValidateVolumeCapabilitiesResponse), nil
+	}
+
+	id := req.GetVolumeId().GetValues()["id"]
+
+	s.Lock()
+	_, ok := s.volumesByID[id]
+	s.Unlock()
+
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "volume not found: %s", id)
+	}
+	return &csi.ValidateVolumeCapabilitiesResponse{Supported: true}, nil
+}
+
+// ListVolumes pages over the volumes created so far, honoring
+// req.StartingToken and req.MaxEntries and returning a NextToken whenever
+// more volumes remain.
+func (s *Service) ListVolumes(
+	ctx context.Context,
+	req *csi.ListVolumesRequest) (
+	*csi.ListVolumesResponse, error) {
+
+	if resp, err, ok := s.override("ListVolumes"); ok {
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*csi.ListVolumesResponse), nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	start := 0
+	if t := req.GetStartingToken(); t != "" {
+		i, err := strconv.Atoi(t)
+		if err != nil {
+			return nil, status.Errorf(
+				codes.Aborted, "invalid starting_token: %s", t)
+		}
+		start = i
+	}
+	if start < 0 || start > len(s.order) {
+		return nil, status.Errorf(
+			codes.Aborted, "starting_token out of range: %s", req.GetStartingToken())
+	}
+
+	end := len(s.order)
+	if max := int(req.GetMaxEntries()); max > 0 && start+max < end {
+		end = start + max
+	}
+
+	entries := make([]*csi.ListVolumesResponse_Result_Entry, 0, end-start)
+	for _, id := range s.order[start:end] {
+		entries = append(
+			entries,
+			&csi.ListVolumesResponse_Result_Entry{VolumeInfo: s.volumesByID[id]})
+	}
+
+	var nextToken string
+	if end < len(s.order) {
+		nextToken = strconv.Itoa(end)
+	}
+
+	return &csi.ListVolumesResponse{
+		Reply: &csi.ListVolumesResponse_Result_{
+			Result: &csi.ListVolumesResponse_Result{
+				Entries:   entries,
+				NextToken: nextToken,
+			},
+		},
+	}, nil
+}
+
+// GetCapacity always reports zero available capacity; callers that need
+// a specific value should use SetNextResponse.
+func (s *Service) GetCapacity(
+	ctx context.Context,
+	req *csi.GetCapacityRequest) (
+	*csi.GetCapacityResponse, error) {
+
+	if resp, err, ok := s.override("GetCapacity"); ok {
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*csi.GetCapacityResponse), nil
+	}
+	return &csi.GetCapacityResponse{}, nil
+}
+
+// ControllerGetCapabilities reports no capabilities; callers that need
+// the fake to advertise a capability should use SetNextResponse.
+func (s *Service) ControllerGetCapabilities(
+	ctx context.Context,
+	req *csi.ControllerGetCapabilitiesRequest) (
+	*csi.ControllerGetCapabilitiesResponse, error) {
+
+	if resp, err, ok := s.override("ControllerGetCapabilities"); ok {
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*csi.ControllerGetCapabilitiesResponse), nil
+	}
+	return &csi.ControllerGetCapabilitiesResponse{}, nil
+}
+
+// ControllerExpandVolume sets the volume's capacity to the requested
+// size and always asks the node to confirm the expansion.
+func (s *Service) ControllerExpandVolume(
+	ctx context.Context,
+	req *csi.ControllerExpandVolumeRequest) (
+	*csi.ControllerExpandVolumeResponse, error) {
+
+	if resp, err, ok := s.override("ControllerExpandVolume"); ok {
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*csi.ControllerExpandVolumeResponse), nil
+	}
+
+	id := req.GetVolumeId().GetValues()["id"]
+
+	s.Lock()
+	defer s.Unlock()
+
+	v, ok := s.volumesByID[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "volume not found: %s", id)
+	}
+	v.CapacityBytes = req.GetCapacityRange().GetRequiredBytes()
+
+	return &csi.ControllerExpandVolumeResponse{
+		CapacityBytes:         v.CapacityBytes,
+		NodeExpansionRequired: true,
+	}, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+//                             Identity Service                               //
+////////////////////////////////////////////////////////////////////////////////
+
+// GetSupportedVersions reports no versions; callers that care about a
+// specific value should use SetNextResponse.
+func (s *Service) GetSupportedVersions(
+	ctx context.Context,
+	req *csi.GetSupportedVersionsRequest) (
+	*csi.GetSupportedVersionsResponse, error) {
+
+	if resp, err, ok := s.override("GetSupportedVersions"); ok {
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*csi.GetSupportedVersionsResponse), nil
+	}
+	return &csi.GetSupportedVersionsResponse{}, nil
+}
+
+// GetPluginInfo reports a fixed, fake plug-in name and version.
+func (s *Service) GetPluginInfo(
+	ctx context.Context,
+	req *csi.GetPluginInfoRequest) (
+	*csi.GetPluginInfoResponse, error) {
+
+	if resp, err, ok := s.override("GetPluginInfo"); ok {
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*csi.GetPluginInfoResponse), nil
+	}
+	return &csi.GetPluginInfoResponse{Name: "fake.csi.storage-interface.io", VendorVersion: "0.0.0"}, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+//                                Node Service                                //
+////////////////////////////////////////////////////////////////////////////////
+
+// NodePublishVolume records req.TargetPath as the published path for
+// req.VolumeId.
+func (s *Service) NodePublishVolume(
+	ctx context.Context,
+	req *csi.NodePublishVolumeRequest) (
+	*csi.NodePublishVolumeResponse, error) {
+
+	if resp, err, ok := s.override("NodePublishVolume"); ok {
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*csi.NodePublishVolumeResponse), nil
+	}
+
+	id := req.GetVolumeId().GetValues()["id"]
+
+	s.Lock()
+	s.published[id] = req.GetTargetPath()
+	s.Unlock()
+
+	return &csi.NodePublishVolumeResponse{}, nil
+}
+
+// NodeUnpublishVolume forgets the published path recorded for
+// req.VolumeId.
+func (s *Service) NodeUnpublishVolume(
+	ctx context.Context,
+	req *csi.NodeUnpublishVolumeRequest) (
+	*csi.NodeUnpublishVolumeResponse, error) {
+
+	if resp, err, ok := s.override("NodeUnpublishVolume"); ok {
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*csi.NodeUnpublishVolumeResponse), nil
+	}
+
+	id := req.GetVolumeId().GetValues()["id"]
+
+	s.Lock()
+	delete(s.published, id)
+	s.Unlock()
+
+	return &csi.NodeUnpublishVolumeResponse{}, nil
+}
+
+// GetNodeID reports a fixed, fake node id.
+func (s *Service) GetNodeID(
+	ctx context.Context,
+	req *csi.GetNodeIDRequest) (
+	*csi.GetNodeIDResponse, error) {
+
+	if resp, err, ok := s.override("GetNodeID"); ok {
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*csi.GetNodeIDResponse), nil
+	}
+	return &csi.GetNodeIDResponse{
+		NodeId: &csi.NodeID{Values: map[string]string{"id": "fake-node"}},
+	}, nil
+}
+
+// ProbeNode always reports the node as ready.
+func (s *Service) ProbeNode(
+	ctx context.Context,
+	req *csi.ProbeNodeRequest) (
+	*csi.ProbeNodeResponse, error) {
+
+	if resp, err, ok := s.override("ProbeNode"); ok {
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*csi.ProbeNodeResponse), nil
+	}
+	return &csi.ProbeNodeResponse{}, nil
+}
+
+// NodeGetCapabilities reports no capabilities; callers that need the
+// fake to advertise a capability should use SetNextResponse.
+func (s *Service) NodeGetCapabilities(
+	ctx context.Context,
+	req *csi.NodeGetCapabilitiesRequest) (
+	*csi.NodeGetCapabilitiesResponse, error) {
+
+	if resp, err, ok := s.override("NodeGetCapabilities"); ok {
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*csi.NodeGetCapabilitiesResponse), nil
+	}
+	return &csi.NodeGetCapabilitiesResponse{}, nil
+}
+
+// NodeExpandVolume reports the volume's current controller-side capacity.
+func (s *Service) NodeExpandVolume(
+	ctx context.Context,
+	req *csi.NodeExpandVolumeRequest) (
+	*csi.NodeExpandVolumeResponse, error) {
+
+	if resp, err, ok := s.override("NodeExpandVolume"); ok {
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*csi.NodeExpandVolumeResponse), nil
+	}
+
+	id := req.GetVolumeId().GetValues()["id"]
+
+	s.Lock()
+	v, ok := s.volumesByID[id]
+	s.Unlock()
+
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "volume not found: %s", id)
+	}
+	return &csi.NodeExpandVolumeResponse{CapacityBytes: v.CapacityBytes}, nil
+}
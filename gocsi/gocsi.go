@@ -7,7 +7,6 @@ import (
 	"log"
 	"net"
 	"os"
-	"plugin"
 	"strings"
 	"sync"
 	"time"
@@ -21,13 +20,33 @@ import (
 var (
 	initOnce      sync.Once
 	endpointCtors = map[string]func() interface{}{}
+	remoteTargets = map[string]string{}
 )
 
+// Register registers the constructor for a named Endpoint that is
+// compiled directly into this binary. Plug-in authors call Register from
+// an init() function, mirroring how identity/controller/node servers are
+// wired directly into drivers such as csi-driver-iscsi, instead of
+// building a Go plug-in shared object.
+func Register(name string, ctor func() Endpoint) {
+	endpointCtors[name] = func() interface{} { return ctor() }
+}
+
+// RegisterRemote registers an out-of-process Endpoint reachable at the
+// given target, a Golang network address of the form "proto://addr"
+// (e.g. "unix:///var/run/csi.sock" or "tcp://127.0.0.1:10000"). It has
+// the same effect as listing "name=proto://addr" in CSI_PLUGINS, but lets
+// callers that manage their own plug-in discovery register a remote
+// endpoint directly.
+func RegisterRemote(name, target string) {
+	remoteTargets[name] = target
+}
+
 // Init initializes the CSI endpoint manager.
 func Init(ctx context.Context) error {
 	var err error
 	initOnce.Do(func() {
-		err = loadSharedObjects(ctx)
+		err = loadPlugins(ctx)
 	})
 	return err
 }
@@ -38,6 +57,13 @@ type Endpoint interface {
 	Init(ctx context.Context) error
 	Serve(ctx context.Context, li net.Listener) error
 	Shutdown(ctx context.Context) error
+
+	// ParameterSchema declares the Parameters/VolumeAttributes keys this
+	// plug-in accepts. The endpoint proxy validates CreateVolume,
+	// ControllerPublishVolume, ValidateVolumeCapabilities, and
+	// NodePublishVolume requests against it before the RPC leaves this
+	// process. Returning a nil ParameterSchema disables validation.
+	ParameterSchema() ParameterSchema
 }
 
 // Service is one configuration of a CSI endpoint's services.
@@ -51,16 +77,28 @@ type Service interface {
 type endpoint struct {
 	once sync.Once
 	name string
+
+	// endp and conn are set for in-process endpoints, constructed from a
+	// registry entry and proxied to over an in-memory pipe.
 	endp Endpoint
 	conn *pipeConn
-	clnt *grpc.ClientConn
+
+	// target is set instead of endp/conn for out-of-process endpoints;
+	// dial connects to it directly rather than over the pipe.
+	target string
+
+	clnt     *grpc.ClientConn
+	logger   Logger
+	liveness *livenessOptions
+
+	livenessCancel context.CancelFunc
 }
 
 var errInvalidEndpointProvider = fmt.Errorf("invalid endpoint provider")
 
 // New returns a CSI endpoint for the specified provider. If no
 // provider matches the specified name a nil value is returned.
-func New(ctx context.Context, name string) (Service, error) {
+func New(ctx context.Context, name string, opts ...Option) (Service, error) {
 
 	// ensure the package is initialized and the shared objects
 	// are loaded and available
@@ -68,51 +106,149 @@ func New(ctx context.Context, name string) (Service, error) {
 		return nil, err
 	}
 
+	o := &options{logger: defaultLogger()}
+	for _, setOpt := range opts {
+		setOpt(o)
+	}
+
+	for k, target := range remoteTargets {
+		if strings.EqualFold(k, name) {
+			return &endpoint{
+				name:     k,
+				target:   target,
+				logger:   o.logger,
+				liveness: o.liveness,
+			}, nil
+		}
+	}
+
 	for k, v := range endpointCtors {
 		if strings.EqualFold(k, name) {
-			o := v()
-			if e, ok := o.(Endpoint); ok {
+			o2 := v()
+			if e, ok := o2.(Endpoint); ok {
 				return &endpoint{
-					name: k,
-					endp: e,
-					conn: newPipeConn(k),
+					name:     k,
+					endp:     e,
+					conn:     newPipeConn(k),
+					logger:   o.logger,
+					liveness: o.liveness,
 				}, nil
 			}
-			return nil, fmt.Errorf("invalid endpoint type: %T", o)
+			return nil, fmt.Errorf("invalid endpoint type: %T", o2)
 		}
 	}
 
 	return nil, errInvalidEndpointProvider
 }
 
+// options are the settings configured via one or more Option values
+// passed to New.
+type options struct {
+	logger   Logger
+	liveness *livenessOptions
+}
+
+// Option configures the Service returned by New.
+type Option func(*options)
+
+// WithLogger sets the Logger used by the interceptor chain installed on
+// every outbound proxy call. The default logger writes to stderr via the
+// standard library's "log" package.
+func WithLogger(l Logger) Option {
+	return func(o *options) { o.logger = l }
+}
+
 func (e *endpoint) Init(ctx context.Context) error {
+	// an out-of-process endpoint's plug-in is already running and
+	// initialized by the time it's dialed
+	if e.target != "" {
+		return nil
+	}
 	return e.endp.Init(ctx)
 }
 
-// Serve starts the piped connection to the Go plug-in that provides
-// the implementation of the CSI services.
+// Serve starts the piped connection to the in-process plug-in that
+// provides the implementation of the CSI services, or, for an
+// out-of-process endpoint, simply blocks until ctx is canceled since the
+// plug-in's gRPC server is already being served elsewhere.
 func (e *endpoint) Serve(
 	ctx context.Context, li net.Listener) (err error) {
 
+	if e.liveness != nil {
+		ctx = contextWithLiveness(ctx, e.liveness)
+
+		if addr := os.Getenv("CSI_LIVENESS_ENDPOINT"); addr != "" {
+			lctx, cancel := context.WithCancel(ctx)
+			e.livenessCancel = cancel
+			go func() {
+				if err := StartMetricsServer(lctx, addr); err != nil {
+					log.Printf("gocsi: metrics server stopped: %v\n", err)
+				}
+			}()
+		}
+	}
+
+	if e.target != "" {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
 	return e.endp.Serve(ctx, e.conn)
 }
 
 // Shutdown gracefully shuts down the server without interrupting any
 // active connections.
 func (e *endpoint) Shutdown(ctx context.Context) error {
+	if e.livenessCancel != nil {
+		e.livenessCancel()
+	}
+	if e.clnt != nil {
+		e.clnt.Close()
+	}
+	if e.target != "" {
+		return nil
+	}
 	e.endp.Shutdown(ctx)
 	e.conn.Close()
 	return nil
 }
 
+// ParameterSchema returns the in-process plug-in's declared schema. An
+// out-of-process endpoint has no local Endpoint to consult and so
+// performs no validation of its own; it is expected to validate
+// Parameters/VolumeAttributes on its own side of the wire instead.
+func (e *endpoint) ParameterSchema() ParameterSchema {
+	if e.endp != nil {
+		return e.endp.ParameterSchema()
+	}
+	return nil
+}
+
 func (e *endpoint) dial(
 	ctx context.Context) (client *grpc.ClientConn, err error) {
 
-	return grpc.DialContext(
-		ctx,
-		e.name,
+	dialOpts := []grpc.DialOption{
 		grpc.WithInsecure(),
-		grpc.WithDialer(e.conn.Dial))
+		grpc.WithUnaryInterceptor(newUnaryClientInterceptor(e.logger)),
+		grpc.WithStreamInterceptor(newStreamClientInterceptor(e.logger)),
+	}
+
+	// an out-of-process endpoint dials its real proto://addr target
+	// instead of the in-memory pipe used for in-process endpoints
+	if e.target != "" {
+		proto, addr, err := ParseProtoAddr(e.target)
+		if err != nil {
+			return nil, err
+		}
+		dialer := func(addr string, timeout time.Duration) (net.Conn, error) {
+			return net.DialTimeout(proto, addr, timeout)
+		}
+		return grpc.DialContext(
+			ctx, addr, append(dialOpts, grpc.WithDialer(dialer))...)
+	}
+
+	return grpc.DialContext(
+		ctx, e.name, append(dialOpts, grpc.WithDialer(e.conn.Dial))...)
 }
 
 func (e *endpoint) dialController(
@@ -146,48 +282,39 @@ func (e *endpoint) dialNode(
 }
 
 ////////////////////////////////////////////////////////////////////////////////
-//                               Go Plug-ins                                  //
+//                            Plug-in Discovery                               //
 ////////////////////////////////////////////////////////////////////////////////
 
-func loadSharedObjects(ctx context.Context) error {
-	// read the paths of the go plug-in files
+// loadPlugins parses CSI_PLUGINS, a CSV list whose entries are either:
+//
+//   - "name=proto://addr", registering an out-of-process endpoint the
+//     same way RegisterRemote does, or
+//   - a path to a Go plug-in shared object built with
+//     `go build -buildmode=plugin`, loaded via loadSharedObject (only
+//     implemented on Linux; see plugin_linux.go and plugin_other.go).
+//
+// Endpoints compiled directly into this binary never go through
+// CSI_PLUGINS; their authors call Register from an init() function
+// instead.
+func loadPlugins(ctx context.Context) error {
 	rdr := csv.NewReader(strings.NewReader(os.Getenv("CSI_PLUGINS")))
-	sos, err := rdr.Read()
+	entries, err := rdr.Read()
 	if err != nil && err != io.EOF {
 		return err
 	}
-	if len(sos) == 0 {
-		return nil
-	}
-
-	// iterate the shared object files and load them one at a time
-	for _, so := range sos {
 
-		// attempt to open the plug-in
-		p, err := plugin.Open(so)
-		if err != nil {
-			return err
+	for _, entry := range entries {
+		if i := strings.IndexByte(entry, '='); i >= 0 {
+			name, target := entry[:i], entry[i+1:]
+			RegisterRemote(name, target)
+			log.Printf("registered remote endpoint: %s=%s\n", name, target)
+			continue
 		}
-		log.Printf("loaded plug-in: %s\n", so)
 
-		epsSym, err := p.Lookup("Endpoints")
-		if err != nil {
+		if err := loadSharedObject(entry); err != nil {
 			return err
 		}
-		eps, ok := epsSym.(*map[string]func() interface{})
-		if !ok {
-			return fmt.Errorf("error: invalid endpoints field: %T", epsSym)
-		}
-
-		if eps == nil {
-			return fmt.Errorf("error: nil endpoints")
-		}
-
-		// record the endpoint provider names and constructors
-		for k, v := range *eps {
-			endpointCtors[k] = v
-			log.Printf("registered endpoint: %s\n", k)
-		}
+		log.Printf("loaded plug-in: %s\n", entry)
 	}
 
 	return nil
@@ -259,6 +386,13 @@ func (e *endpoint) CreateVolume(
 	req *csi.CreateVolumeRequest) (
 	*csi.CreateVolumeResponse, error) {
 
+	params, err := e.ParameterSchema().validate(req.GetParameters())
+	if err != nil {
+		return nil, err
+	}
+	req.Parameters = params
+	req.CreateSecrets = secretsFromContext(ctx, req.GetCreateSecrets())
+
 	c, err := e.dialController(ctx)
 	if err != nil {
 		return nil, err
@@ -271,6 +405,8 @@ func (e *endpoint) DeleteVolume(
 	req *csi.DeleteVolumeRequest) (
 	*csi.DeleteVolumeResponse, error) {
 
+	req.DeleteSecrets = secretsFromContext(ctx, req.GetDeleteSecrets())
+
 	c, err := e.dialController(ctx)
 	if err != nil {
 		return nil, err
@@ -283,6 +419,11 @@ func (e *endpoint) ControllerPublishVolume(
 	req *csi.ControllerPublishVolumeRequest) (
 	*csi.ControllerPublishVolumeResponse, error) {
 
+	if err := e.ParameterSchema().validateAttributes(req.GetVolumeAttributes()); err != nil {
+		return nil, err
+	}
+	req.ControllerPublishSecrets = secretsFromContext(ctx, req.GetControllerPublishSecrets())
+
 	c, err := e.dialController(ctx)
 	if err != nil {
 		return nil, err
@@ -295,6 +436,8 @@ func (e *endpoint) ControllerUnpublishVolume(
 	req *csi.ControllerUnpublishVolumeRequest) (
 	*csi.ControllerUnpublishVolumeResponse, error) {
 
+	req.ControllerUnpublishSecrets = secretsFromContext(ctx, req.GetControllerUnpublishSecrets())
+
 	c, err := e.dialController(ctx)
 	if err != nil {
 		return nil, err
@@ -307,6 +450,10 @@ func (e *endpoint) ValidateVolumeCapabilities(
 	req *csi.ValidateVolumeCapabilitiesRequest) (
 	*csi.ValidateVolumeCapabilitiesResponse, error) {
 
+	if err := e.ParameterSchema().validateAttributes(req.GetVolumeAttributes()); err != nil {
+		return nil, err
+	}
+
 	c, err := e.dialController(ctx)
 	if err != nil {
 		return nil, err
@@ -350,6 +497,18 @@ func (e *endpoint) ControllerGetCapabilities(
 	return c.ControllerGetCapabilities(ctx, req)
 }
 
+func (e *endpoint) ControllerExpandVolume(
+	ctx context.Context,
+	req *csi.ControllerExpandVolumeRequest) (
+	*csi.ControllerExpandVolumeResponse, error) {
+
+	c, err := e.dialController(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.ControllerExpandVolume(ctx, req)
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 //                             Identity Service                               //
 ////////////////////////////////////////////////////////////////////////////////
@@ -387,6 +546,11 @@ func (e *endpoint) NodePublishVolume(
 	req *csi.NodePublishVolumeRequest) (
 	*csi.NodePublishVolumeResponse, error) {
 
+	if err := e.ParameterSchema().validateAttributes(req.GetVolumeAttributes()); err != nil {
+		return nil, err
+	}
+	req.NodePublishSecrets = secretsFromContext(ctx, req.GetNodePublishSecrets())
+
 	c, err := e.dialNode(ctx)
 	if err != nil {
 		return nil, err
@@ -399,6 +563,8 @@ func (e *endpoint) NodeUnpublishVolume(
 	req *csi.NodeUnpublishVolumeRequest) (
 	*csi.NodeUnpublishVolumeResponse, error) {
 
+	req.NodeUnpublishSecrets = secretsFromContext(ctx, req.GetNodeUnpublishSecrets())
+
 	c, err := e.dialNode(ctx)
 	if err != nil {
 		return nil, err
@@ -441,3 +607,15 @@ func (e *endpoint) NodeGetCapabilities(
 	}
 	return c.NodeGetCapabilities(ctx, req)
 }
+
+func (e *endpoint) NodeExpandVolume(
+	ctx context.Context,
+	req *csi.NodeExpandVolumeRequest) (
+	*csi.NodeExpandVolumeResponse, error) {
+
+	c, err := e.dialNode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.NodeExpandVolume(ctx, req)
+}
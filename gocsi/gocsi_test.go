@@ -2,8 +2,22 @@ package gocsi_test
 
 import (
 	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
 	"github.com/container-storage-interface/libraries/gocsi"
 	"github.com/container-storage-interface/libraries/gocsi/csi"
 )
@@ -48,8 +62,252 @@ var _ = Describe("CSI", func() {
 			Ω(res).Should(Equal(listVolumesResponse))
 		})
 	})
+
+	Context("Metrics", func() {
+		It("Should increment the RPC counter for a request served over the wire", func() {
+			before := totalRPCCount()
+
+			_, err := svc.ListVolumes(
+				ctx, &csi.ListVolumesRequest{StartingToken: "1"})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(totalRPCCount()).Should(BeNumerically(">", before))
+		})
+	})
+
+	Context("Expand", func() {
+		It("Should resize a volume and require node expansion", func() {
+			volID := &csi.VolumeID{Values: map[string]string{"id": "vol-001"}}
+
+			cres, err := svc.ControllerExpandVolume(
+				ctx, &csi.ControllerExpandVolumeRequest{
+					VolumeId:      volID,
+					CapacityRange: &csi.CapacityRange{RequiredBytes: 2 * gib},
+				})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(cres.CapacityBytes).Should(Equal(int64(2 * gib)))
+			Ω(cres.NodeExpansionRequired).Should(BeTrue())
+
+			// An online expansion against an already published volume
+			// reports the previous capacity until the node actually grows
+			// the live filesystem.
+			nres, err := svc.NodeExpandVolume(
+				ctx, &csi.NodeExpandVolumeRequest{VolumeId: volID})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(nres.CapacityBytes).Should(Equal(int64(0)))
+
+			// An offline expansion that passes a staging target path grows
+			// the filesystem immediately and reports the new capacity.
+			nres, err = svc.NodeExpandVolume(
+				ctx, &csi.NodeExpandVolumeRequest{
+					VolumeId:          volID,
+					StagingTargetPath: "/mnt/vol-001",
+				})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(nres.CapacityBytes).Should(Equal(int64(2 * gib)))
+		})
+	})
+
+	Context("Logging", func() {
+		It("Should not leak secrets into the captured log output", func() {
+			logger := &captureLogger{}
+
+			lsvc, err := gocsi.New(ctx, "mock", gocsi.WithLogger(logger))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			go func() {
+				lsvc.Serve(ctx, nil)
+			}()
+			defer lsvc.Shutdown(ctx)
+
+			_, err = lsvc.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+				NodePublishSecrets: map[string]string{"token": "hunter2"},
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(logger.String()).ShouldNot(ContainSubstring("hunter2"))
+			Ω(logger.String()).Should(ContainSubstring("***"))
+		})
+	})
+
+	Context("Secrets", func() {
+		It("Should merge secrets attached via WithSecrets into the outbound request", func() {
+			logger := &captureLogger{}
+
+			lsvc, err := gocsi.New(ctx, "mock", gocsi.WithLogger(logger))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			go func() {
+				lsvc.Serve(ctx, nil)
+			}()
+			defer lsvc.Shutdown(ctx)
+
+			sctx := gocsi.WithSecrets(ctx, map[string]string{"token": "hunter2"})
+			req := &csi.NodePublishVolumeRequest{}
+			_, err = lsvc.NodePublishVolume(sctx, req)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(req.GetNodePublishSecrets()).Should(HaveKeyWithValue("token", "hunter2"))
+
+			Ω(logger.String()).ShouldNot(ContainSubstring("hunter2"))
+			Ω(logger.String()).Should(ContainSubstring("***"))
+		})
+	})
 })
 
+// This exercises the migration away from Go's plugin package: an
+// Endpoint registered with gocsi.Register is served in-process exactly
+// like one loaded from a shared object, and an Endpoint registered with
+// gocsi.RegisterRemote is dialed directly over a real transport.
+var _ = Describe("Registry", func() {
+	It("Should proxy to an Endpoint registered with Register", func() {
+		ctx := context.Background()
+		gocsi.Register("static-mock", func() gocsi.Endpoint { return &bareEndpoint{} })
+
+		svc, err := gocsi.New(ctx, "static-mock")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		go svc.Serve(ctx, nil)
+		defer svc.Shutdown(ctx)
+
+		_, err = svc.GetPluginInfo(ctx, &csi.GetPluginInfoRequest{})
+		Ω(status.Code(err)).Should(Equal(codes.Unimplemented))
+	})
+
+	It("Should dial an Endpoint registered with RegisterRemote over a real unix socket", func() {
+		ctx := context.Background()
+
+		dir, err := ioutil.TempDir("", "gocsi-test")
+		Ω(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		sock := filepath.Join(dir, "remote.sock")
+		li, err := net.Listen("unix", sock)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		grpcServer := grpc.NewServer()
+		go grpcServer.Serve(li)
+		defer grpcServer.Stop()
+
+		gocsi.RegisterRemote("remote-mock", "unix://"+sock)
+
+		svc, err := gocsi.New(ctx, "remote-mock")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		_, err = svc.GetPluginInfo(ctx, &csi.GetPluginInfoRequest{})
+		Ω(status.Code(err)).Should(Equal(codes.Unimplemented))
+	})
+})
+
+// This exercises the endpoint proxy's validation layer: a plug-in that
+// declares a ParameterSchema has its CreateVolume/ControllerPublishVolume/
+// NodePublishVolume requests checked against it before the RPC ever
+// leaves this process.
+var _ = Describe("Parameters", func() {
+	var (
+		ctx context.Context
+		svc gocsi.Service
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		gocsi.Register("schema-mock", func() gocsi.Endpoint { return &schemaEndpoint{} })
+
+		var err error
+		svc, err = gocsi.New(ctx, "schema-mock")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		go svc.Serve(ctx, nil)
+	})
+
+	AfterEach(func() {
+		svc.Shutdown(ctx)
+	})
+
+	It("Should reject an unknown parameter before dialing the plug-in", func() {
+		_, err := svc.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name:       "vol",
+			Parameters: map[string]string{"bogus": "x"},
+		})
+		Ω(status.Code(err)).Should(Equal(codes.InvalidArgument))
+	})
+
+	It("Should reject a request missing a required parameter", func() {
+		_, err := svc.CreateVolume(ctx, &csi.CreateVolumeRequest{Name: "vol"})
+		Ω(status.Code(err)).Should(Equal(codes.InvalidArgument))
+	})
+
+	It("Should let a valid request reach the plug-in", func() {
+		// schemaEndpoint registers no CSI services, so a request that
+		// clears validation still fails, but with Unimplemented instead
+		// of InvalidArgument, proving it was dialed rather than rejected.
+		_, err := svc.CreateVolume(ctx, &csi.CreateVolumeRequest{
+			Name:       "vol",
+			Parameters: map[string]string{"type": "ssd"},
+		})
+		Ω(status.Code(err)).Should(Equal(codes.Unimplemented))
+	})
+})
+
+// bareEndpoint is a minimal gocsi.Endpoint that registers no CSI services,
+// just enough to prove the registry/transport wiring works independent of
+// any particular plug-in's RPCs.
+type bareEndpoint struct{}
+
+func (e *bareEndpoint) Init(ctx context.Context) error { return nil }
+
+func (e *bareEndpoint) Serve(ctx context.Context, li net.Listener) error {
+	return grpc.NewServer().Serve(li)
+}
+
+func (e *bareEndpoint) Shutdown(ctx context.Context) error { return nil }
+
+func (e *bareEndpoint) ParameterSchema() gocsi.ParameterSchema { return nil }
+
+// schemaEndpoint is a bareEndpoint that declares a ParameterSchema
+// requiring a "type" key with a fixed set of allowed values.
+type schemaEndpoint struct{ bareEndpoint }
+
+func (e *schemaEndpoint) ParameterSchema() gocsi.ParameterSchema {
+	return gocsi.ParameterSchema{
+		"type": gocsi.ParameterField{Required: true, Allowed: []string{"ssd", "hdd"}},
+	}
+}
+
+const gib = 1024 * 1024 * 1024
+
+// totalRPCCount sums the csi_rpcs_total counter across every method/code
+// label pair, so a test can assert it moved without depending on the
+// exact gRPC full method name a particular CSI service generates.
+func totalRPCCount() float64 {
+	mfs, err := prometheus.DefaultGatherer.Gather()
+	Ω(err).ShouldNot(HaveOccurred())
+
+	var total float64
+	for _, mf := range mfs {
+		if mf.GetName() != "csi_rpcs_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			total += m.GetCounter().GetValue()
+		}
+	}
+	return total
+}
+
+// captureLogger is a gocsi.Logger that records every formatted line for
+// assertions instead of writing to stderr.
+type captureLogger struct {
+	lines []string
+}
+
+func (l *captureLogger) Printf(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *captureLogger) String() string {
+	return strings.Join(l.lines, "\n")
+}
+
 var volInfos = []*csi.VolumeInfo{
 	&csi.VolumeInfo{
 		Id: &csi.VolumeID{
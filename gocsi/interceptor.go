@@ -0,0 +1,202 @@
+package gocsi
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Logger is the subset of a structured or leveled logger (the standard
+// library's log.Logger, klog, zap's SugaredLogger, ...) that the
+// interceptor chain needs in order to emit a line per RPC.
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+func defaultLogger() Logger {
+	return log.New(os.Stderr, "", log.LstdFlags)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+//                                 Client                                      //
+////////////////////////////////////////////////////////////////////////////////
+
+// newUnaryClientInterceptor times and logs every outbound unary RPC the
+// endpoint proxies to its plug-in, sanitizing sensitive fields first.
+func newUnaryClientInterceptor(logger Logger) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption) error {
+
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		logRPC(logger, method, start, req, reply, err)
+		return err
+	}
+}
+
+// newStreamClientInterceptor logs one line once an outbound streaming RPC
+// has been opened, with the error (if any) from opening it; the stream's
+// later messages and final status are not logged. The CSI services currently proxied are all unary, but the
+// interceptor is installed alongside the unary one so a future streaming
+// RPC is covered without additional wiring.
+func newStreamClientInterceptor(logger Logger) grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption) (grpc.ClientStream, error) {
+
+		start := time.Now()
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		logRPC(logger, method, start, nil, nil, err)
+		return cs, err
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+//                                 Server                                      //
+////////////////////////////////////////////////////////////////////////////////
+
+// NewLoggingUnaryServerInterceptor returns a grpc.UnaryServerInterceptor
+// that times and logs every unary RPC handled by a CSI plug-in's gRPC
+// server, sanitizing sensitive fields first. Plug-ins install it with
+// grpc.NewServer(grpc.UnaryInterceptor(...)). A nil logger falls back to
+// the standard library's "log" package.
+func NewLoggingUnaryServerInterceptor(logger Logger) grpc.UnaryServerInterceptor {
+	if logger == nil {
+		logger = defaultLogger()
+	}
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logRPC(logger, info.FullMethod, start, req, resp, err)
+		return resp, err
+	}
+}
+
+// ChainUnaryServerInterceptors returns a grpc.UnaryServerInterceptor that
+// runs interceptors in order, each wrapping the next, so a gRPC server
+// that only accepts a single grpc.UnaryInterceptor option (e.g. both
+// NewLoggingUnaryServerInterceptor and NewMetricsUnaryServerInterceptor)
+// can install more than one.
+func ChainUnaryServerInterceptors(
+	interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		chained := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], chained
+			chained = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, next)
+			}
+		}
+		return chained(ctx, req)
+	}
+}
+
+// NewLoggingStreamServerInterceptor returns a grpc.StreamServerInterceptor
+// counterpart to NewLoggingUnaryServerInterceptor. A nil logger falls back
+// to the standard library's "log" package.
+func NewLoggingStreamServerInterceptor(logger Logger) grpc.StreamServerInterceptor {
+	if logger == nil {
+		logger = defaultLogger()
+	}
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+
+		start := time.Now()
+		err := handler(srv, ss)
+		logRPC(logger, info.FullMethod, start, nil, nil, err)
+		return err
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+//                                Sanitizing                                   //
+////////////////////////////////////////////////////////////////////////////////
+
+const redacted = "***"
+
+func logRPC(
+	logger Logger,
+	method string,
+	start time.Time,
+	req, resp interface{},
+	err error) {
+
+	logger.Printf(
+		"gocsi: method=%s duration=%s code=%s req=%s resp=%s",
+		method, time.Since(start), status.Code(err),
+		sanitize(req), sanitize(resp))
+}
+
+// sanitize renders v as a string suitable for logging, replacing any field
+// tagged `csi_secret` or whose name contains "Secret" with a fixed redacted
+// value. This mirrors the protosanitizer approach used by other CSI
+// drivers without requiring the generated CSI types to carry any
+// additional behavior.
+func sanitize(v interface{}) string {
+	if v == nil {
+		return "<nil>"
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return "<nil>"
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Sprintf("%v", v)
+	}
+
+	rt := rv.Type()
+	parts := make([]string, 0, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if f.PkgPath != "" {
+			continue // unexported field
+		}
+		if isSecretField(f) {
+			parts = append(parts, fmt.Sprintf("%s:%s", f.Name, redacted))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s:%v", f.Name, rv.Field(i).Interface()))
+	}
+	return fmt.Sprintf("%s{%s}", rt.Name(), strings.Join(parts, " "))
+}
+
+func isSecretField(f reflect.StructField) bool {
+	if tag, ok := f.Tag.Lookup("csi_secret"); ok && tag != "false" {
+		return true
+	}
+	return strings.Contains(f.Name, "Secret")
+}
@@ -0,0 +1,123 @@
+package gocsi
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// livenessOptions configures the background probe that drives a plug-in's
+// HealthServer, enabled via WithLiveness.
+type livenessOptions struct {
+	failThreshold int
+	probeInterval time.Duration
+}
+
+// WithLiveness enables the liveness subsystem for the endpoint returned by
+// New: a grpc.health.v1.Health service is registered against the plug-in's
+// gRPC server, its status driven by a probe that runs every
+// probeInterval. After failThreshold consecutive probe failures the
+// health status transitions to NOT_SERVING; a single successful probe
+// restores SERVING. The endpoint proxy threads failThreshold and
+// probeInterval through the ctx passed to the plug-in's Serve, where they
+// are recovered with LivenessOptionsFromContext and used to drive the
+// plug-in's own RegisterHealthServer/WatchHealth wiring. If the
+// CSI_LIVENESS_ENDPOINT environment variable is set, a Prometheus
+// /metrics HTTP listener is also started at that address (parsed by
+// ParseProtoAddr).
+func WithLiveness(failThreshold int, probeInterval time.Duration) Option {
+	return func(o *options) {
+		o.liveness = &livenessOptions{
+			failThreshold: failThreshold,
+			probeInterval: probeInterval,
+		}
+	}
+}
+
+// DefaultFailThreshold and DefaultProbeInterval are the values
+// LivenessOptionsFromContext returns when the endpoint being served was
+// built without WithLiveness, so a plug-in's WatchHealth loop always has
+// sane values to run with.
+const (
+	DefaultFailThreshold = 3
+	DefaultProbeInterval = 5 * time.Second
+)
+
+type livenessContextKey struct{}
+
+// contextWithLiveness returns a copy of ctx carrying o, so the Serve
+// implementation the ctx is passed to can recover it with
+// LivenessOptionsFromContext.
+func contextWithLiveness(ctx context.Context, o *livenessOptions) context.Context {
+	return context.WithValue(ctx, livenessContextKey{}, o)
+}
+
+// LivenessOptionsFromContext returns the failThreshold and probeInterval
+// configured via WithLiveness for the endpoint being served, recovered
+// from the ctx passed to Endpoint.Serve. It returns DefaultFailThreshold
+// and DefaultProbeInterval if the endpoint was built without
+// WithLiveness, so a plug-in's WatchHealth loop can call this
+// unconditionally.
+func LivenessOptionsFromContext(ctx context.Context) (failThreshold int, probeInterval time.Duration) {
+	o, ok := ctx.Value(livenessContextKey{}).(*livenessOptions)
+	if !ok {
+		return DefaultFailThreshold, DefaultProbeInterval
+	}
+	return o.failThreshold, o.probeInterval
+}
+
+// HealthServer is the reference grpc/health implementation, re-exported so
+// plug-in authors do not need to import google.golang.org/grpc/health
+// directly.
+type HealthServer = health.Server
+
+// NewHealthServer returns a HealthServer with the overall service already
+// marked SERVING.
+func NewHealthServer() *HealthServer {
+	hs := health.NewServer()
+	hs.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	return hs
+}
+
+// RegisterHealthServer registers hs on grpcServer so CSI orchestrators can
+// probe plug-in liveness with the standard grpc.health.v1.Health service.
+func RegisterHealthServer(grpcServer *grpc.Server, hs *HealthServer) {
+	grpc_health_v1.RegisterHealthServer(grpcServer, hs)
+}
+
+// WatchHealth calls probe every interval until ctx is canceled, marking hs
+// NOT_SERVING once probe has failed failThreshold times in a row and
+// SERVING again as soon as a probe succeeds.
+func WatchHealth(
+	ctx context.Context,
+	hs *HealthServer,
+	probe func(ctx context.Context) error,
+	interval time.Duration,
+	failThreshold int) {
+
+	var failures int
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := probe(ctx); err != nil {
+				failures++
+			} else {
+				failures = 0
+			}
+
+			status := grpc_health_v1.HealthCheckResponse_SERVING
+			if failures >= failThreshold {
+				status = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+			}
+			hs.SetServingStatus("", status)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package gocsi
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	rpcsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "csi",
+			Name:      "rpcs_total",
+			Help:      "Total number of CSI RPCs, labeled by method and status code.",
+		},
+		[]string{"method", "code"})
+
+	rpcsInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "csi",
+			Name:      "rpcs_in_flight",
+			Help:      "Number of CSI RPCs currently being served, labeled by method.",
+		},
+		[]string{"method"})
+
+	rpcDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "csi",
+			Name:      "rpc_duration_seconds",
+			Help:      "Latency of CSI RPCs, labeled by method.",
+		},
+		[]string{"method"})
+)
+
+func init() {
+	prometheus.MustRegister(rpcsTotal, rpcsInFlight, rpcDuration)
+}
+
+// NewMetricsUnaryServerInterceptor returns a grpc.UnaryServerInterceptor
+// that records Prometheus counters, an in-flight gauge, and a latency
+// histogram for every CSI RPC it observes.
+func NewMetricsUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		rpcsInFlight.WithLabelValues(info.FullMethod).Inc()
+		defer rpcsInFlight.WithLabelValues(info.FullMethod).Dec()
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		rpcDuration.WithLabelValues(info.FullMethod).Observe(
+			time.Since(start).Seconds())
+		rpcsTotal.WithLabelValues(
+			info.FullMethod, status.Code(err).String()).Inc()
+
+		return resp, err
+	}
+}
+
+// StartMetricsServer parses protoAddr with ParseProtoAddr and serves the
+// Prometheus handler at /metrics on that address until ctx is canceled.
+func StartMetricsServer(ctx context.Context, protoAddr string) error {
+	proto, addr, err := ParseProtoAddr(protoAddr)
+	if err != nil {
+		return err
+	}
+
+	li, err := net.Listen(proto, addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	srv := &http.Server{Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	return srv.Serve(li)
+}
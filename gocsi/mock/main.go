@@ -7,10 +7,12 @@ import (
 	"net"
 	"os"
 	"regexp"
+	"sync"
 
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 
+	"github.com/container-storage-interface/libraries/gocsi"
 	"github.com/container-storage-interface/libraries/gocsi/mock/csi"
 )
 import "log"
@@ -67,12 +69,37 @@ func parseProtoAddr(protoAddr string) (proto string, addr string, err error) {
 ////////////////////////////////////////////////////////////////////////////////
 
 // Endpoints is an exported symbol that provides a host program
-// with a map of the endpoint provider names and constructors.
+// with a map of the endpoint provider names and constructors. It is only
+// consulted when the mock is built as a Go plug-in shared object and
+// loaded via a CSI_PLUGINS path; see init below for the preferred,
+// cross-platform registration path.
 var Endpoints = map[string]func() interface{}{
 	"mock": func() interface{} { return &endpoint{} },
 }
 
-type endpoint struct{}
+// init registers the mock provider with gocsi's static registry. This is
+// the migration path away from the Go plug-in shared object above: a CSI
+// plug-in that is compiled directly into a host binary alongside gocsi
+// registers itself the same way, without ever going through Go's plugin
+// package.
+func init() {
+	gocsi.Register("mock", func() gocsi.Endpoint { return &endpoint{} })
+}
+
+type endpoint struct {
+	sync.Mutex
+	volumes     map[string]*volumeState
+	probeCancel context.CancelFunc
+}
+
+// volumeState tracks the capacity of a single in-memory mock volume across
+// ControllerExpandVolume and NodeExpandVolume calls so the mock can exercise
+// both the offline (staged) and online (already published) expansion flows.
+type volumeState struct {
+	capacity     int64
+	prevCapacity int64
+	staged       bool
+}
 
 type listVolResult struct{}
 
@@ -98,22 +125,64 @@ func (v *listVolResult) Data() []byte {
 // Endpoint.Init
 func (e *endpoint) Init(ctx context.Context) error {
 	log.Println("mock.Init")
+	e.volumes = map[string]*volumeState{}
 	return nil
 }
 
 // Endpoint.Serve
 func (e *endpoint) Serve(ctx context.Context, li net.Listener) error {
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(gocsi.ChainUnaryServerInterceptors(
+			gocsi.NewLoggingUnaryServerInterceptor(nil),
+			gocsi.NewMetricsUnaryServerInterceptor())),
+		grpc.StreamInterceptor(gocsi.NewLoggingStreamServerInterceptor(nil)))
 	csi.RegisterControllerServer(grpcServer, e)
 	csi.RegisterIdentityServer(grpcServer, e)
 	csi.RegisterNodeServer(grpcServer, e)
+
+	probeCtx, cancel := context.WithCancel(ctx)
+	e.Lock()
+	e.probeCancel = cancel
+	e.Unlock()
+
+	failThreshold, probeInterval := gocsi.LivenessOptionsFromContext(ctx)
+	hs := gocsi.NewHealthServer()
+	gocsi.RegisterHealthServer(grpcServer, hs)
+	go gocsi.WatchHealth(probeCtx, hs, e.probe, probeInterval, failThreshold)
+
 	log.Println("mock.Serve")
 	return grpcServer.Serve(li)
 }
 
+// probe drives the health service's serving status by exercising the same
+// RPCs a CO would use to determine plug-in readiness.
+func (e *endpoint) probe(ctx context.Context) error {
+	if _, err := e.GetPluginInfo(ctx, &csi.GetPluginInfoRequest{}); err != nil {
+		return err
+	}
+	if _, err := e.ProbeNode(ctx, &csi.ProbeNodeRequest{}); err != nil {
+		return err
+	}
+	return nil
+}
+
 //  Endpoint.Shutdown
 func (e *endpoint) Shutdown(ctx context.Context) error {
 	log.Println("mock.Shutdown")
+
+	e.Lock()
+	cancel := e.probeCancel
+	e.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+
+	return nil
+}
+
+// ParameterSchema returns nil: the mock plug-in accepts any Parameters
+// or VolumeAttributes without validation.
+func (e *endpoint) ParameterSchema() gocsi.ParameterSchema {
 	return nil
 }
 
@@ -194,7 +263,48 @@ func (e *endpoint) ControllerGetCapabilities(
 	req *csi.ControllerGetCapabilitiesRequest) (
 	*csi.ControllerGetCapabilitiesResponse, error) {
 
-	return nil, nil
+	return &csi.ControllerGetCapabilitiesResponse{
+		Capabilities: []*csi.ControllerServiceCapability{
+			&csi.ControllerServiceCapability{
+				Type: &csi.ControllerServiceCapability_Rpc{
+					Rpc: &csi.ControllerServiceCapability_RPC{
+						Type: csi.ControllerServiceCapability_RPC_EXPAND_VOLUME,
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+func (e *endpoint) ControllerExpandVolume(
+	ctx context.Context,
+	req *csi.ControllerExpandVolumeRequest) (
+	*csi.ControllerExpandVolumeResponse, error) {
+
+	id := req.GetVolumeId().GetValues()["id"]
+
+	e.Lock()
+	defer e.Unlock()
+
+	if e.volumes == nil {
+		e.volumes = map[string]*volumeState{}
+	}
+
+	v := e.volumes[id]
+	if v == nil {
+		v = &volumeState{}
+		e.volumes[id] = v
+	}
+	v.prevCapacity = v.capacity
+	v.capacity = req.GetCapacityRange().GetRequiredBytes()
+
+	log.Printf(
+		"mock.ControllerExpandVolume id=%s capacity=%d\n", id, v.capacity)
+
+	return &csi.ControllerExpandVolumeResponse{
+		CapacityBytes:         v.capacity,
+		NodeExpansionRequired: true,
+	}, nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -258,7 +368,59 @@ func (e *endpoint) NodeGetCapabilities(
 	req *csi.NodeGetCapabilitiesRequest) (
 	*csi.NodeGetCapabilitiesResponse, error) {
 
-	return nil, nil
+	return &csi.NodeGetCapabilitiesResponse{
+		Capabilities: []*csi.NodeServiceCapability{
+			&csi.NodeServiceCapability{
+				Type: &csi.NodeServiceCapability_Rpc{
+					Rpc: &csi.NodeServiceCapability_RPC{
+						Type: csi.NodeServiceCapability_RPC_EXPAND_VOLUME,
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+func (e *endpoint) NodeExpandVolume(
+	ctx context.Context,
+	req *csi.NodeExpandVolumeRequest) (
+	*csi.NodeExpandVolumeResponse, error) {
+
+	id := req.GetVolumeId().GetValues()["id"]
+
+	e.Lock()
+	defer e.Unlock()
+
+	v := e.volumes[id]
+	if v == nil {
+		return nil, fmt.Errorf("mock: unknown volume: %s", id)
+	}
+
+	// Offline expansion: the volume is staged but not yet published, so the
+	// mock grows the filesystem immediately and remembers that it did.
+	if req.GetStagingTargetPath() != "" {
+		v.staged = true
+		log.Printf(
+			"mock.NodeExpandVolume id=%s staged capacity=%d\n",
+			id, v.capacity)
+		return &csi.NodeExpandVolumeResponse{CapacityBytes: v.capacity}, nil
+	}
+
+	// Online expansion: the volume is already published. If no prior
+	// NodeExpandVolume call grew the filesystem during staging, the mock
+	// reports the previous capacity to show the resize has not happened yet.
+	if !v.staged {
+		log.Printf(
+			"mock.NodeExpandVolume id=%s online pending capacity=%d\n",
+			id, v.prevCapacity)
+		return &csi.NodeExpandVolumeResponse{
+			CapacityBytes: v.prevCapacity,
+		}, nil
+	}
+
+	log.Printf(
+		"mock.NodeExpandVolume id=%s online capacity=%d\n", id, v.capacity)
+	return &csi.NodeExpandVolumeResponse{CapacityBytes: v.capacity}, nil
 }
 
 var volInfos = []*csi.VolumeInfo{
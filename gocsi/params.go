@@ -0,0 +1,144 @@
+package gocsi
+
+import (
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+//                             Parameter Schema                                //
+////////////////////////////////////////////////////////////////////////////////
+
+// ParameterField describes the validation rules for a single key in a
+// CreateVolume Parameters map or a VolumeAttributes map threaded through
+// later Controller/Node RPCs.
+type ParameterField struct {
+	// Required marks the key as mandatory.
+	Required bool
+
+	// Allowed, if non-empty, is the closed set of values the key may
+	// take; any other value is rejected.
+	Allowed []string
+}
+
+// ParameterSchema declares the keys a plug-in accepts, keyed by parameter
+// name. A nil or empty ParameterSchema disables validation entirely; the
+// endpoint proxy passes Parameters/VolumeAttributes maps through
+// unchanged.
+type ParameterSchema map[string]ParameterField
+
+// validate checks a CreateVolume Parameters map against the schema,
+// rejecting unknown keys and enforcing the Required/Allowed rules
+// declared for each known key. It returns a copy of params with every
+// value trimmed of surrounding whitespace, leaving the map the caller
+// passed in untouched. An empty schema returns params itself, untrimmed.
+func (s ParameterSchema) validate(params map[string]string) (map[string]string, error) {
+	if len(s) == 0 {
+		return params, nil
+	}
+
+	cleaned := make(map[string]string, len(params))
+	for k, v := range params {
+		cleaned[k] = strings.TrimSpace(v)
+	}
+
+	for k := range cleaned {
+		if _, ok := s[k]; !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown parameter: %s", k)
+		}
+	}
+
+	for k, field := range s {
+		v, ok := cleaned[k]
+		if !ok {
+			if field.Required {
+				return nil, status.Errorf(
+					codes.InvalidArgument, "missing required parameter: %s", k)
+			}
+			continue
+		}
+		if len(field.Allowed) > 0 && !stringInSlice(v, field.Allowed) {
+			return nil, status.Errorf(
+				codes.InvalidArgument, "parameter %s: invalid value %q", k, v)
+		}
+	}
+
+	return cleaned, nil
+}
+
+// validateAttributes checks a VolumeAttributes map against s's Allowed
+// rules. Unlike validate, it does not reject a key absent from s or
+// enforce Required: CreateVolume echoes Parameters back as
+// VolumeAttributes alongside any keys the plug-in itself attaches, so by
+// the time a Controller/Node RPC threads VolumeAttributes back through
+// here the map may legitimately contain more than the original
+// Parameters schema describes. Required was already enforced against
+// the originating CreateVolume Parameters.
+func (s ParameterSchema) validateAttributes(attrs map[string]string) error {
+	if len(s) == 0 {
+		return nil
+	}
+
+	for k, v := range attrs {
+		field, ok := s[k]
+		if !ok || len(field.Allowed) == 0 {
+			continue
+		}
+		if !stringInSlice(strings.TrimSpace(v), field.Allowed) {
+			return status.Errorf(
+				codes.InvalidArgument, "parameter %s: invalid value %q", k, v)
+		}
+	}
+
+	return nil
+}
+
+func stringInSlice(v string, vals []string) bool {
+	for _, a := range vals {
+		if a == v {
+			return true
+		}
+	}
+	return false
+}
+
+////////////////////////////////////////////////////////////////////////////////
+//                                 Secrets                                     //
+////////////////////////////////////////////////////////////////////////////////
+
+type secretsKey struct{}
+
+// WithSecrets returns a copy of ctx carrying secrets. The endpoint proxy
+// merges secrets into the appropriate *Secrets field of every outbound
+// Controller/Node RPC that accepts one, so an operator can supply
+// per-call credentials instead of embedding them in every request
+// struct, and a single plug-in can be reused across tenants with
+// different credentials.
+func WithSecrets(ctx context.Context, secrets map[string]string) context.Context {
+	return context.WithValue(ctx, secretsKey{}, secrets)
+}
+
+// secretsFromContext merges the secrets attached to ctx via WithSecrets
+// into existing, with existing taking precedence for any key both
+// define, and returns the result. It returns existing unchanged if ctx
+// carries no secrets.
+func secretsFromContext(
+	ctx context.Context, existing map[string]string) map[string]string {
+
+	fromCtx, _ := ctx.Value(secretsKey{}).(map[string]string)
+	if len(fromCtx) == 0 {
+		return existing
+	}
+
+	merged := make(map[string]string, len(fromCtx)+len(existing))
+	for k, v := range fromCtx {
+		merged[k] = v
+	}
+	for k, v := range existing {
+		merged[k] = v
+	}
+	return merged
+}
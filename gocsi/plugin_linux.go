@@ -0,0 +1,42 @@
+//go:build linux
+// +build linux
+
+package gocsi
+
+import (
+	"fmt"
+	"log"
+	"plugin"
+)
+
+// loadSharedObject loads a Go plug-in shared object built with
+// `go build -buildmode=plugin` and registers the endpoint constructors it
+// exports via its "Endpoints" symbol. Go's plugin package only supports
+// Linux with a matching toolchain, which is why this file carries the
+// "linux" build tag; see plugin_other.go for the fallback used when
+// building for other platforms.
+func loadSharedObject(so string) error {
+	p, err := plugin.Open(so)
+	if err != nil {
+		return err
+	}
+
+	epsSym, err := p.Lookup("Endpoints")
+	if err != nil {
+		return err
+	}
+	eps, ok := epsSym.(*map[string]func() interface{})
+	if !ok {
+		return fmt.Errorf("error: invalid endpoints field: %T", epsSym)
+	}
+	if eps == nil {
+		return fmt.Errorf("error: nil endpoints")
+	}
+
+	for k, v := range *eps {
+		endpointCtors[k] = v
+		log.Printf("registered endpoint: %s\n", k)
+	}
+
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package gocsi
+
+import "fmt"
+
+// loadSharedObject always fails on non-Linux platforms, where Go's
+// plugin package is unsupported. Use Register for an in-process endpoint
+// or an out-of-process CSI_PLUGINS entry ("name=proto://addr") instead.
+func loadSharedObject(so string) error {
+	return fmt.Errorf(
+		"gocsi: shared object plug-ins are only supported on linux: %s", so)
+}